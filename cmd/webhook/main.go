@@ -0,0 +1,86 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	gokitlog "github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	nadclientset "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/k8snetworkplumbingwg/network-resources-injector/pkg/controlswitches"
+	"github.com/k8snetworkplumbingwg/network-resources-injector/pkg/webhook"
+)
+
+func main() {
+	bindAddress := flag.String("bind-address", "0.0.0.0", "address the webhook listens on")
+	port := flag.Int("port", 8443, "port the webhook listens on")
+	cert := flag.String("tls-cert-file", "/etc/webhook/certs/tls.crt", "TLS certificate file")
+	key := flag.String("tls-private-key-file", "/etc/webhook/certs/tls.key", "TLS private key file")
+	metricsPort := flag.Int("metrics-port", 0, "port to serve /metrics on; 0 serves it from the webhook's own HTTPS port instead of a separate listener")
+	logLevel := flag.String("log-level", "info", "log verbosity: debug, info, warn or error")
+	flag.Parse()
+
+	webhook.SetLogger(level.NewFilter(gokitlog.NewLogfmtLogger(os.Stderr), webhook.ParseLogLevel(*logLevel)))
+
+	switches := controlswitches.New()
+	if err := switches.InitControlSwitches(); err != nil {
+		log.Fatalf("failed to initialize control switches: %v", err)
+	}
+	webhook.SetControlSwitches(switches)
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		log.Fatalf("failed to build in-cluster config: %v", err)
+	}
+
+	nadClient, err := nadclientset.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("failed to build NetworkAttachmentDefinition client: %v", err)
+	}
+	webhook.SetNadClient(nadClient)
+
+	k8sClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("failed to build Kubernetes client: %v", err)
+	}
+	webhook.SetK8sClient(k8sClient)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mutate", webhook.MutateHandler)
+	mux.HandleFunc("/validate", webhook.ValidateHandler)
+	if *metricsPort == 0 {
+		mux.Handle("/metrics", webhook.MetricsHandler)
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", webhook.MetricsHandler)
+		go func() {
+			log.Fatal(http.ListenAndServe(fmt.Sprintf("%s:%d", *bindAddress, *metricsPort), metricsMux))
+		}()
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", *bindAddress, *port),
+		Handler: mux,
+	}
+	log.Fatal(server.ListenAndServeTLS(*cert, *key))
+}
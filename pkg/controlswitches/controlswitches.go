@@ -0,0 +1,122 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controlswitches centralizes the feature toggles that decide which
+// optional mutations the injector applies to a pod. Toggles are read once,
+// at startup, from the environment so that the rest of the webhook package
+// can consult a single, already-resolved structure instead of re-reading
+// env vars on every admission request.
+package controlswitches
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	envVarInjectHugePageDownApi  = "INJECT_HUGEPAGE_DOWNWARD_API"
+	envVarHonorExistingResources = "HONOR_RESOURCES"
+	envVarResourceNameKeys       = "RESOURCE_NAME_KEYS"
+
+	defaultResourceNameKeys = "intel.com/sriov"
+)
+
+// ControlSwitches holds the resolved set of feature toggles the webhook
+// package consults when deciding how to mutate a pod.
+type ControlSwitches struct {
+	injectHugePageDownApi  bool
+	honorExistingResources bool
+	resourceNameKeys       []string
+}
+
+// New returns a zero-value ControlSwitches; callers must call
+// InitControlSwitches (or SetupControlSwitchesUnitTests in tests) before use.
+func New() *ControlSwitches {
+	return &ControlSwitches{}
+}
+
+// InitControlSwitches resolves every toggle from its environment variable,
+// falling back to the documented default when the variable is unset.
+func (cs *ControlSwitches) InitControlSwitches() error {
+	cs.injectHugePageDownApi = boolFromEnv(envVarInjectHugePageDownApi, false)
+	cs.honorExistingResources = boolFromEnv(envVarHonorExistingResources, false)
+
+	resourceNameKeys := os.Getenv(envVarResourceNameKeys)
+	if resourceNameKeys == "" {
+		resourceNameKeys = defaultResourceNameKeys
+	}
+	cs.resourceNameKeys = splitAndTrim(resourceNameKeys)
+
+	return nil
+}
+
+// SetupControlSwitchesUnitTests builds a ControlSwitches from explicit
+// values instead of the environment, so unit tests can exercise a known
+// configuration without mutating process-global state.
+func SetupControlSwitchesUnitTests(injectHugePageDownApi *bool, honorExistingResources *bool, resourceNameKeys *string) *ControlSwitches {
+	cs := New()
+	if injectHugePageDownApi != nil {
+		cs.injectHugePageDownApi = *injectHugePageDownApi
+	}
+	if honorExistingResources != nil {
+		cs.honorExistingResources = *honorExistingResources
+	}
+	if resourceNameKeys != nil && *resourceNameKeys != "" {
+		cs.resourceNameKeys = splitAndTrim(*resourceNameKeys)
+	}
+	return cs
+}
+
+// IsInjectHugePageDownApiEnabled reports whether the hugepage downward API
+// mutation is enabled.
+func (cs *ControlSwitches) IsInjectHugePageDownApiEnabled() bool {
+	return cs.injectHugePageDownApi
+}
+
+// IsHonorExistingResourcesEnabled reports whether resource requests already
+// present on a container should be left untouched.
+func (cs *ControlSwitches) IsHonorExistingResourcesEnabled() bool {
+	return cs.honorExistingResources
+}
+
+// ResourceNameKeys returns the set of pod annotation keys treated as
+// resource-name selectors.
+func (cs *ControlSwitches) ResourceNameKeys() []string {
+	return cs.resourceNameKeys
+}
+
+func boolFromEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+func splitAndTrim(csv string) []string {
+	parts := strings.Split(csv, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
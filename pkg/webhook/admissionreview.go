@@ -0,0 +1,140 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+const (
+	apiVersionV1      = "admission.k8s.io/v1"
+	apiVersionV1beta1 = "admission.k8s.io/v1beta1"
+)
+
+// admissionReview lets MutateHandler and ValidateHandler run a single code
+// path, built around admission.k8s.io/v1, regardless of which AdmissionReview
+// version the apiserver actually negotiated. v1beta1 is still negotiated by
+// some older/downstream distributions and by apiservers migrating off it
+// alongside Multus itself.
+type admissionReview interface {
+	// v1 returns an admission.k8s.io/v1 AdmissionReview with this review's
+	// request, so handler logic never needs to branch on wire version.
+	v1() *admissionv1.AdmissionReview
+	// setResponse copies the Response half of a completed v1 AdmissionReview
+	// back into this review, converting it to the original wire version.
+	setResponse(resp *admissionv1.AdmissionResponse)
+	// marshal serializes this review in its original wire version.
+	marshal() ([]byte, error)
+}
+
+type admissionReviewV1 struct {
+	review *admissionv1.AdmissionReview
+}
+
+func (a *admissionReviewV1) v1() *admissionv1.AdmissionReview { return a.review }
+
+func (a *admissionReviewV1) setResponse(resp *admissionv1.AdmissionResponse) {
+	a.review.Response = resp
+}
+
+func (a *admissionReviewV1) marshal() ([]byte, error) {
+	return json.Marshal(a.review)
+}
+
+type admissionReviewV1beta1 struct {
+	review *admissionv1beta1.AdmissionReview
+}
+
+func (a *admissionReviewV1beta1) v1() *admissionv1.AdmissionReview {
+	if a.review.Request == nil {
+		return &admissionv1.AdmissionReview{TypeMeta: a.review.TypeMeta}
+	}
+	req := a.review.Request
+	return &admissionv1.AdmissionReview{
+		TypeMeta: a.review.TypeMeta,
+		Request: &admissionv1.AdmissionRequest{
+			UID:                req.UID,
+			Kind:               req.Kind,
+			Resource:           req.Resource,
+			SubResource:        req.SubResource,
+			RequestKind:        req.RequestKind,
+			RequestResource:    req.RequestResource,
+			RequestSubResource: req.RequestSubResource,
+			Name:               req.Name,
+			Namespace:          req.Namespace,
+			Operation:          admissionv1.Operation(req.Operation),
+			UserInfo:           req.UserInfo,
+			Object:             req.Object,
+			OldObject:          req.OldObject,
+			DryRun:             req.DryRun,
+			Options:            req.Options,
+		},
+	}
+}
+
+func (a *admissionReviewV1beta1) setResponse(resp *admissionv1.AdmissionResponse) {
+	if resp == nil {
+		return
+	}
+	a.review.Response = &admissionv1beta1.AdmissionResponse{
+		UID:              resp.UID,
+		Allowed:          resp.Allowed,
+		Result:           resp.Result,
+		Patch:            resp.Patch,
+		PatchType:        (*admissionv1beta1.PatchType)(resp.PatchType),
+		AuditAnnotations: resp.AuditAnnotations,
+		Warnings:         resp.Warnings,
+	}
+}
+
+func (a *admissionReviewV1beta1) marshal() ([]byte, error) {
+	return json.Marshal(a.review)
+}
+
+// decodeAdmissionReview sniffs the apiVersion field of a raw AdmissionReview
+// body and decodes it with the matching typed decoder, so callers never
+// have to special-case v1beta1 themselves.
+func decodeAdmissionReview(body []byte) (admissionReview, error) {
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, err
+	}
+
+	switch meta.APIVersion {
+	case "", apiVersionV1:
+		ar, err := deserializeAdmissionReview(body)
+		if err != nil {
+			return nil, err
+		}
+		return &admissionReviewV1{review: ar}, nil
+	case apiVersionV1beta1:
+		ar := &admissionv1beta1.AdmissionReview{}
+		if _, _, err := codecs.UniversalDeserializer().Decode(body, nil, ar); err != nil {
+			return nil, err
+		}
+		if ar.Request == nil {
+			return nil, fmt.Errorf("request is empty")
+		}
+		return &admissionReviewV1beta1{review: ar}, nil
+	default:
+		return nil, fmt.Errorf("unsupported AdmissionReview apiVersion %q", meta.APIVersion)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/network-resources-injector/pkg/controlswitches"
+)
+
+// admissionReviewBody builds a raw AdmissionReview request body of the
+// given apiVersion, carrying pod as the object under review.
+func admissionReviewBody(apiVersion string, pod corev1.Pod) []byte {
+	podRaw, err := json.Marshal(pod)
+	Expect(err).NotTo(HaveOccurred())
+
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       "AdmissionReview",
+		"request": map[string]interface{}{
+			"uid": "fake-uid",
+			"object": k8sruntime.RawExtension{
+				Raw: podRaw,
+			},
+		},
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return body
+}
+
+var _ = Describe("AdmissionReview version handling", func() {
+	BeforeEach(func() {
+		structure := controlswitches.SetupControlSwitchesUnitTests(createBool(false), createBool(false), createString(""))
+		structure.InitControlSwitches()
+		SetControlSwitches(structure)
+	})
+
+	DescribeTable("MutateHandler round-trips the negotiated AdmissionReview version",
+		func(apiVersion string) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			}
+			body := admissionReviewBody(apiVersion, pod)
+
+			req := httptest.NewRequest("POST", "https://fakewebhook/mutate", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			MutateHandler(w, req)
+			resp := w.Result()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var ar map[string]interface{}
+			Expect(json.NewDecoder(resp.Body).Decode(&ar)).To(Succeed())
+			Expect(ar["apiVersion"]).To(Equal(apiVersion))
+
+			response, ok := ar["response"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(response["uid"]).To(Equal("fake-uid"))
+			Expect(response["allowed"]).To(Equal(true))
+		},
+		Entry("admission.k8s.io/v1", apiVersionV1),
+		Entry("admission.k8s.io/v1beta1", apiVersionV1beta1),
+	)
+
+	It("rejects an AdmissionReview with an unrecognized apiVersion", func() {
+		body := admissionReviewBody("admission.k8s.io/v2", corev1.Pod{})
+		req := httptest.NewRequest("POST", "https://fakewebhook/mutate", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		MutateHandler(w, req)
+		resp := w.Result()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
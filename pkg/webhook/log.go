@@ -0,0 +1,90 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// logger is the package-level sink every admission-request log line is
+// written through. It defaults to an info-level logger on stderr so the
+// webhook is never silent out of the box; SetLogger lets the binary wire up
+// its own level and sink, and lets tests capture output into a buffer.
+var logger log.Logger = level.NewFilter(log.NewLogfmtLogger(os.Stderr), level.AllowInfo())
+
+// SetLogger replaces the package-level logger. Callers typically wrap l in
+// a level.NewFilter first so --log-level is honored.
+func SetLogger(l log.Logger) {
+	logger = l
+}
+
+// ParseLogLevel maps the values accepted by --log-level ("debug", "info",
+// "warn", "error") to a go-kit/log/level filter option, defaulting to info
+// for an empty or unrecognized value.
+func ParseLogLevel(value string) level.Option {
+	switch value {
+	case "debug":
+		return level.AllowDebug()
+	case "warn":
+		return level.AllowWarn()
+	case "error":
+		return level.AllowError()
+	default:
+		return level.AllowInfo()
+	}
+}
+
+// logAtResult picks the go-kit/log/level a log line should be emitted at for
+// a given admission result, so that --log-level=warn or --log-level=error
+// still surfaces denials and errors instead of silencing the very events
+// this logging exists to report.
+func logAtResult(logger log.Logger, result string) log.Logger {
+	switch result {
+	case resultDenied:
+		return level.Warn(logger)
+	case resultError:
+		return level.Error(logger)
+	default:
+		return level.Info(logger)
+	}
+}
+
+// logAdmissionResult emits the single structured log line every admission
+// request produces, regardless of whether it was handled by MutateHandler
+// or ValidateHandler. Field names are part of the injector's contract with
+// downstream log consumers (Loki/ELK) and must not change casually.
+func logAdmissionResult(uid string, pod *corev1.Pod, networks string, resourceRequests string, patchOps int, start time.Time, result string) {
+	namespace, name := "", ""
+	if pod != nil {
+		namespace = pod.ObjectMeta.Namespace
+		name = pod.ObjectMeta.Name
+	}
+
+	logAtResult(logger, result).Log(
+		"uid", uid,
+		"namespace", namespace,
+		"pod", name,
+		"resource_requests", resourceRequests,
+		"networks", networks,
+		"patch_ops", patchOps,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"result", result,
+	)
+}
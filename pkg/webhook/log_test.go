@@ -0,0 +1,88 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"io/ioutil"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logging", func() {
+	var buf *bytes.Buffer
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		SetLogger(log.NewLogfmtLogger(buf))
+	})
+
+	AfterEach(func() {
+		SetLogger(log.NewLogfmtLogger(ioutil.Discard))
+	})
+
+	It("emits a single log line with the documented field names", func() {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-ns"},
+		}
+		logAdmissionResult("fake-uid", pod, "test-ns/net1", "", 1, time.Now(), resultAllowed)
+
+		line := buf.String()
+		Expect(line).To(ContainSubstring("uid=fake-uid"))
+		Expect(line).To(ContainSubstring("namespace=test-ns"))
+		Expect(line).To(ContainSubstring("pod=test-pod"))
+		Expect(line).To(ContainSubstring("networks=test-ns/net1"))
+		Expect(line).To(ContainSubstring("patch_ops=1"))
+		Expect(line).To(ContainSubstring("result=allowed"))
+		Expect(line).To(ContainSubstring("duration_ms="))
+	})
+
+	Describe("logAtResult", func() {
+		It("varies the level with the admission result so --log-level=warn still surfaces denials and errors", func() {
+			filtered := level.NewFilter(log.NewLogfmtLogger(buf), level.AllowWarn())
+
+			logAtResult(filtered, resultAllowed).Log("msg", "allowed should be suppressed")
+			Expect(buf.String()).To(BeEmpty())
+
+			logAtResult(filtered, resultDenied).Log("msg", "denied should appear")
+			Expect(buf.String()).To(ContainSubstring("denied should appear"))
+
+			buf.Reset()
+			logAtResult(filtered, resultError).Log("msg", "error should appear")
+			Expect(buf.String()).To(ContainSubstring("error should appear"))
+		})
+	})
+
+	Describe("ParseLogLevel", func() {
+		It("defaults an unrecognized value to info, which suppresses debug lines", func() {
+			filtered := level.NewFilter(log.NewLogfmtLogger(buf), ParseLogLevel("nonsense"))
+			level.Debug(filtered).Log("msg", "should be suppressed")
+			Expect(buf.String()).To(BeEmpty())
+		})
+
+		It("debug allows debug lines through", func() {
+			filtered := level.NewFilter(log.NewLogfmtLogger(buf), ParseLogLevel("debug"))
+			level.Debug(filtered).Log("msg", "should appear")
+			Expect(buf.String()).To(ContainSubstring("should appear"))
+		})
+	})
+})
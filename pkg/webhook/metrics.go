@@ -0,0 +1,80 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	resultAllowed = "allowed"
+	resultDenied  = "denied"
+	resultError   = "error"
+
+	objectKindPod                        = "pod"
+	objectKindNetworkAttachmentDefinition = "network-attachment-definition"
+)
+
+// metricsRegistry is a private registry, not the global
+// prometheus.DefaultRegisterer, so that importing this package never has
+// side effects on a process that embeds it alongside other instrumented
+// components.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	admissionRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nri_admission_requests_total",
+			Help: "Total number of admission requests handled, by outcome and object kind.",
+		},
+		[]string{"result", "object_kind"},
+	)
+
+	admissionDurationSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "nri_admission_duration_seconds",
+			Help:    "Time taken to handle an admission request, by object kind.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"object_kind"},
+	)
+
+	admissionRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nri_admission_requests_in_flight",
+			Help: "Number of admission requests currently being handled.",
+		},
+	)
+)
+
+func init() {
+	metricsRegistry.MustRegister(admissionRequestsTotal)
+	metricsRegistry.MustRegister(admissionDurationSeconds)
+	metricsRegistry.MustRegister(admissionRequestsInFlight)
+}
+
+// MetricsHandler serves the admission metrics in the Prometheus exposition
+// format.
+var MetricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+
+// observeAdmission records one completed admission request: its outcome,
+// object kind, and how long handling it took.
+func observeAdmission(objectKind string, result string, start time.Time) {
+	admissionRequestsTotal.WithLabelValues(result, objectKind).Inc()
+	admissionDurationSeconds.WithLabelValues(objectKind).Observe(time.Since(start).Seconds())
+}
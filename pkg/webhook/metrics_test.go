@@ -0,0 +1,87 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/k8snetworkplumbingwg/network-resources-injector/pkg/controlswitches"
+)
+
+var _ = Describe("Metrics", func() {
+	Describe("observeAdmission", func() {
+		It("increments the request counter for the given result and object kind", func() {
+			admissionRequestsTotal.Reset()
+
+			observeAdmission(objectKindPod, resultAllowed, time.Now())
+
+			expected := strings.NewReader(`
+				# HELP nri_admission_requests_total Total number of admission requests handled, by outcome and object kind.
+				# TYPE nri_admission_requests_total counter
+				nri_admission_requests_total{object_kind="pod",result="allowed"} 1
+			`)
+			Expect(testutil.CollectAndCompare(admissionRequestsTotal, expected, "nri_admission_requests_total")).To(Succeed())
+		})
+	})
+
+	Describe("nri_admission_requests_total is not double-counted", func() {
+		BeforeEach(func() {
+			structure := controlswitches.SetupControlSwitchesUnitTests(createBool(false), createBool(false), createString(""))
+			structure.InitControlSwitches()
+			SetControlSwitches(structure)
+		})
+
+		malformedPod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Namespace:   "default",
+				Annotations: map[string]string{networksAnnotationKey: "net1@if1@if2"},
+			},
+		}
+
+		It("MutateHandler records exactly one result for a pod with a malformed networks annotation", func() {
+			admissionRequestsTotal.Reset()
+
+			req := httptest.NewRequest("POST", "https://fakewebhook/mutate", bytes.NewBuffer(admissionReviewBody(apiVersionV1, malformedPod)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			MutateHandler(w, req)
+
+			Expect(testutil.ToFloat64(admissionRequestsTotal)).To(Equal(float64(1)))
+		})
+
+		It("ValidateHandler records exactly one result for a pod with a malformed networks annotation", func() {
+			admissionRequestsTotal.Reset()
+
+			req := httptest.NewRequest("POST", "https://fakewebhook/validate", bytes.NewBuffer(admissionReviewBody(apiVersionV1, malformedPod)))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			ValidateHandler(w, req)
+
+			Expect(testutil.ToFloat64(admissionRequestsTotal)).To(Equal(float64(1)))
+		})
+	})
+})
@@ -0,0 +1,286 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/k8snetworkplumbingwg/multus-cni.v4/pkg/types"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// k8sClient is used to run SubjectAccessReviews when a pod selects a
+// NetworkAttachmentDefinition outside its own namespace. It is nil until
+// SetK8sClient is called.
+var k8sClient kubernetes.Interface
+
+// SetK8sClient stores the clientset used to check whether a pod's service
+// account may read NetworkAttachmentDefinitions in another namespace.
+func SetK8sClient(client kubernetes.Interface) {
+	k8sClient = client
+}
+
+// selectionError is a single, structured reason a pod's network selections
+// failed validation.
+type selectionError struct {
+	// Namespace and Name identify the selection that failed, as the user
+	// wrote it (Namespace is already resolved to the pod's own namespace
+	// when the selection did not specify one).
+	Namespace string
+	Name      string
+	Interface string
+	Reason    string
+}
+
+func (e *selectionError) Error() string {
+	if e.Interface != "" {
+		return fmt.Sprintf("network selection %s/%s@%s: %s", e.Namespace, e.Name, e.Interface, e.Reason)
+	}
+	return fmt.Sprintf("network selection %s/%s: %s", e.Namespace, e.Name, e.Reason)
+}
+
+// ValidateHandler handles AdmissionReview requests for pods about to be
+// created and rejects admission when the pod's network selections
+// reference a NetworkAttachmentDefinition that does not exist, is in a
+// namespace the pod's service account cannot read, or collide with another
+// selection on the same pod over the same interface name.
+func ValidateHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	admissionRequestsInFlight.Inc()
+	defer admissionRequestsInFlight.Dec()
+
+	ar := readAdmissionRequest(w, r)
+	if ar == nil {
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult("", nil, "", "", 0, start, resultError)
+		return
+	}
+	v1Review := ar.v1()
+
+	pod, err := deserializePod(v1Review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error deserializing pod: %v", err), http.StatusBadRequest)
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult(string(v1Review.Request.UID), nil, "", "", 0, start, resultError)
+		return
+	}
+
+	networks, defaultNetworks, err := parsePodNetworkAnnotations(*pod, nil)
+	if err != nil {
+		if prepErr := prepareAdmissionReviewResponse(false, err.Error(), v1Review); prepErr != nil {
+			http.Error(w, prepErr.Error(), http.StatusInternalServerError)
+			observeAdmission(objectKindPod, resultError, start)
+			logAdmissionResult(string(v1Review.Request.UID), pod, "", "", 0, start, resultError)
+			return
+		}
+		ar.setResponse(v1Review.Response)
+		writeVersionedResponse(w, ar)
+		observeAdmission(objectKindPod, resultDenied, start)
+		logAdmissionResult(string(v1Review.Request.UID), pod, "", "", 0, start, resultDenied)
+		return
+	}
+
+	allSelections := append(append([]*types.NetworkSelectionElement{}, networks...), defaultNetworks...)
+
+	selections := append(append([]*selectionWithInterface{}, withInterfaces(networks)...), withInterfaces(defaultNetworks)...)
+	errs, lookupErrs := validateNetworkSelections(*pod, selections)
+	if len(errs) > 0 {
+		if err := prepareAdmissionReviewResponse(false, joinSelectionErrors(errs), v1Review); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			observeAdmission(objectKindPod, resultError, start)
+			logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(allSelections), "", 0, start, resultError)
+			return
+		}
+		ar.setResponse(v1Review.Response)
+		writeVersionedResponse(w, ar)
+		observeAdmission(objectKindPod, resultDenied, start)
+		logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(allSelections), "", 0, start, resultDenied)
+		return
+	}
+
+	if len(lookupErrs) > 0 {
+		// A transient failure to reach the apiserver isn't proof the NAD is
+		// missing, so admit rather than hard-denying - the pod falls back
+		// to the old "stuck in ContainerCreating" behavior instead of being
+		// rejected outright, and the error is still surfaced via the
+		// result=error metric and log line for alerting.
+		if err := prepareAdmissionReviewResponse(true, joinErrors(lookupErrs), v1Review); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			observeAdmission(objectKindPod, resultError, start)
+			logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(allSelections), "", 0, start, resultError)
+			return
+		}
+		ar.setResponse(v1Review.Response)
+		writeVersionedResponse(w, ar)
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(allSelections), "", 0, start, resultError)
+		return
+	}
+
+	if err := prepareAdmissionReviewResponse(true, "", v1Review); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(allSelections), "", 0, start, resultError)
+		return
+	}
+	ar.setResponse(v1Review.Response)
+	writeVersionedResponse(w, ar)
+	observeAdmission(objectKindPod, resultAllowed, start)
+	logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(allSelections), "", 0, start, resultAllowed)
+}
+
+// selectionWithInterface pairs a parsed network selection with the
+// interface name it requests, which is empty when the selection does not
+// request one; duplicate-interface detection skips empty names.
+type selectionWithInterface struct {
+	namespace string
+	name      string
+	iface     string
+}
+
+func withInterfaces(elements []*types.NetworkSelectionElement) []*selectionWithInterface {
+	out := make([]*selectionWithInterface, 0, len(elements))
+	for _, e := range elements {
+		out = append(out, &selectionWithInterface{
+			namespace: e.Namespace,
+			name:      e.Name,
+			iface:     e.InterfaceRequest,
+		})
+	}
+	return out
+}
+
+// validateNetworkSelections checks each selection against the cluster
+// (does the NAD exist, can the pod's service account read it) and against
+// the other selections on the same pod (do two selections claim the same
+// interface name). A NAD confirmed absent, a namespace the pod cannot
+// access, or a duplicate interface name are returned as selectionErrors
+// and deny the pod. A transient failure to even reach the apiserver is
+// returned separately in lookupErrs - it isn't proof the NAD is missing,
+// so it must not deny the pod the same way.
+func validateNetworkSelections(pod corev1.Pod, selections []*selectionWithInterface) ([]*selectionError, []error) {
+	var errs []*selectionError
+	var lookupErrs []error
+
+	seenInterfaces := map[string]bool{}
+	for _, sel := range selections {
+		if sel.iface != "" {
+			if seenInterfaces[sel.iface] {
+				errs = append(errs, &selectionError{
+					Namespace: sel.namespace,
+					Name:      sel.name,
+					Interface: sel.iface,
+					Reason:    "requested interface name is already used by another network selection on this pod",
+				})
+			}
+			seenInterfaces[sel.iface] = true
+		}
+
+		if nadClient == nil {
+			continue
+		}
+
+		_, err := nadClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(sel.namespace).Get(context.Background(), sel.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			errs = append(errs, &selectionError{
+				Namespace: sel.namespace,
+				Name:      sel.name,
+				Interface: sel.iface,
+				Reason:    "NetworkAttachmentDefinition does not exist",
+			})
+			continue
+		}
+		if err != nil {
+			lookupErrs = append(lookupErrs, fmt.Errorf("failed to look up NetworkAttachmentDefinition %s/%s: %v", sel.namespace, sel.name, err))
+			continue
+		}
+
+		if sel.namespace != pod.Namespace && !canAccessNamespace(pod, sel.namespace) {
+			errs = append(errs, &selectionError{
+				Namespace: sel.namespace,
+				Name:      sel.name,
+				Interface: sel.iface,
+				Reason:    "pod's service account is not permitted to read NetworkAttachmentDefinitions in this namespace",
+			})
+		}
+	}
+
+	return errs, lookupErrs
+}
+
+// canAccessNamespace reports whether the pod's service account is allowed
+// to get network-attachment-definitions in namespace, via a
+// SubjectAccessReview. It fails open (returns true) when no clientset has
+// been configured, since unit tests and some deployments run without one.
+func canAccessNamespace(pod corev1.Pod, namespace string) bool {
+	if k8sClient == nil {
+		return true
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User: fmt.Sprintf("system:serviceaccount:%s:%s", pod.Namespace, serviceAccount),
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "get",
+				Group:     "k8s.cni.cncf.io",
+				Resource:  "network-attachment-definitions",
+			},
+		},
+	}
+
+	result, err := k8sClient.AuthorizationV1().SubjectAccessReviews().Create(context.Background(), sar, metav1.CreateOptions{})
+	if err != nil {
+		return false
+	}
+	return result.Status.Allowed
+}
+
+// joinErrors renders a set of errors into the single JSON-array message
+// string prepareAdmissionReviewResponse expects, whether they are
+// selectionErrors denying the pod or lookupErrs only reporting a
+// transient failure.
+func joinErrors(errs []error) string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	b, err := json.Marshal(msgs)
+	if err != nil {
+		return msgs[0]
+	}
+	return string(b)
+}
+
+func joinSelectionErrors(errs []*selectionError) string {
+	asErrors := make([]error, len(errs))
+	for i, e := range errs {
+		asErrors[i] = e
+	}
+	return joinErrors(asErrors)
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	nadfake "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/clientset/versioned/fake"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// allowSAR installs a reactor on client that allows (or denies) every
+// SubjectAccessReview it is asked to create, so tests can drive
+// canAccessNamespace without a real API server.
+func allowSAR(client *k8sfake.Clientset, allowed bool) {
+	client.PrependReactor("create", "subjectaccessreviews", func(action clientgotesting.Action) (bool, k8sruntime.Object, error) {
+		sar := action.(clientgotesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		sar.Status.Allowed = allowed
+		return true, sar, nil
+	})
+}
+
+var _ = Describe("ValidateHandler", func() {
+	AfterEach(func() {
+		SetNadClient(nil)
+		SetK8sClient(nil)
+	})
+
+	validateReviewBody := func(pod corev1.Pod) []byte {
+		podRaw, err := json.Marshal(pod)
+		Expect(err).NotTo(HaveOccurred())
+
+		body, err := json.Marshal(map[string]interface{}{
+			"apiVersion": apiVersionV1,
+			"kind":       "AdmissionReview",
+			"request": map[string]interface{}{
+				"uid": "fake-uid",
+				"object": k8sruntime.RawExtension{
+					Raw: podRaw,
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return body
+	}
+
+	doValidate := func(pod corev1.Pod) map[string]interface{} {
+		req := httptest.NewRequest("POST", "https://fakewebhook/validate", bytes.NewBuffer(validateReviewBody(pod)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		ValidateHandler(w, req)
+		resp := w.Result()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		var ar map[string]interface{}
+		Expect(json.NewDecoder(resp.Body).Decode(&ar)).To(Succeed())
+		response, ok := ar["response"].(map[string]interface{})
+		Expect(ok).To(BeTrue())
+		return response
+	}
+
+	Context("the pod's network selections are all satisfiable", func() {
+		It("allows the pod", func() {
+			SetNadClient(nadfake.NewSimpleClientset(&nadv1.NetworkAttachmentDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "sriov-net", Namespace: "default"},
+			}))
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: map[string]string{networksAnnotationKey: "sriov-net@net0"},
+				},
+			}
+			response := doValidate(pod)
+			Expect(response["allowed"]).To(Equal(true))
+		})
+	})
+
+	Context("a selection references a NetworkAttachmentDefinition that does not exist", func() {
+		It("denies the pod", func() {
+			SetNadClient(nadfake.NewSimpleClientset())
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: map[string]string{networksAnnotationKey: "missing-net"},
+				},
+			}
+			response := doValidate(pod)
+			Expect(response["allowed"]).To(Equal(false))
+			status, ok := response["status"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(status["message"]).To(ContainSubstring("NetworkAttachmentDefinition does not exist"))
+		})
+	})
+
+	Context("a selection references a NAD in a namespace the pod cannot access", func() {
+		It("denies the pod", func() {
+			SetNadClient(nadfake.NewSimpleClientset(&nadv1.NetworkAttachmentDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "sriov-net", Namespace: "other-ns"},
+			}))
+			k8sClientset := k8sfake.NewSimpleClientset()
+			allowSAR(k8sClientset, false)
+			SetK8sClient(k8sClientset)
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: map[string]string{networksAnnotationKey: "other-ns/sriov-net"},
+				},
+			}
+			response := doValidate(pod)
+			Expect(response["allowed"]).To(Equal(false))
+			status, ok := response["status"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(status["message"]).To(ContainSubstring("not permitted to read NetworkAttachmentDefinitions"))
+		})
+	})
+
+	Context("a NAD lookup fails transiently (not a confirmed absence)", func() {
+		It("admits the pod rather than denying on an inconclusive lookup", func() {
+			client := nadfake.NewSimpleClientset()
+			client.PrependReactor("get", "network-attachment-definitions", func(action clientgotesting.Action) (bool, k8sruntime.Object, error) {
+				return true, nil, fmt.Errorf("apiserver unavailable")
+			})
+			SetNadClient(client)
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: map[string]string{networksAnnotationKey: "sriov-net"},
+				},
+			}
+			response := doValidate(pod)
+			Expect(response["allowed"]).To(Equal(true))
+			status, ok := response["status"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(status["message"]).To(ContainSubstring("failed to look up NetworkAttachmentDefinition"))
+		})
+	})
+
+	Context("two selections request the same interface name", func() {
+		It("denies the pod", func() {
+			SetNadClient(nadfake.NewSimpleClientset(
+				&nadv1.NetworkAttachmentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "net1", Namespace: "default"}},
+				&nadv1.NetworkAttachmentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "net2", Namespace: "default"}},
+			))
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: map[string]string{networksAnnotationKey: "net1@net0,net2@net0"},
+				},
+			}
+			response := doValidate(pod)
+			Expect(response["allowed"]).To(Equal(false))
+			status, ok := response["status"].(map[string]interface{})
+			Expect(ok).To(BeTrue())
+			Expect(status["message"]).To(ContainSubstring("already used by another network selection"))
+		})
+	})
+})
+
+var _ = Describe("canAccessNamespace", func() {
+	AfterEach(func() {
+		SetK8sClient(nil)
+	})
+
+	Context("no clientset has been configured", func() {
+		It("fails open", func() {
+			Expect(canAccessNamespace(corev1.Pod{}, "other-ns")).To(BeTrue())
+		})
+	})
+
+	Context("the SubjectAccessReview allows the request", func() {
+		It("returns true", func() {
+			client := k8sfake.NewSimpleClientset()
+			allowSAR(client, true)
+			SetK8sClient(client)
+
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}
+			Expect(canAccessNamespace(pod, "other-ns")).To(BeTrue())
+		})
+	})
+
+	Context("the SubjectAccessReview denies the request", func() {
+		It("returns false", func() {
+			client := k8sfake.NewSimpleClientset()
+			allowSAR(client, false)
+			SetK8sClient(client)
+
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}
+			Expect(canAccessNamespace(pod, "other-ns")).To(BeFalse())
+		})
+	})
+})
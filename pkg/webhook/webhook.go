@@ -0,0 +1,443 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook implements the HTTP handlers backing the admission
+// webhook: MutateHandler parses the network selections of pods and admits
+// them without a patch, and ValidateHandler rejects pods whose network
+// selections cannot be satisfied.
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	nadv1 "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/apis/k8s.cni.cncf.io/v1"
+	nadclientset "github.com/k8snetworkplumbingwg/network-attachment-definition-client/pkg/client/clientset/versioned"
+	"gopkg.in/k8snetworkplumbingwg/multus-cni.v4/pkg/types"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"github.com/k8snetworkplumbingwg/network-resources-injector/pkg/controlswitches"
+	nritypes "github.com/k8snetworkplumbingwg/network-resources-injector/pkg/types"
+)
+
+const (
+	networksAnnotationKey        = "k8s.v1.cni.cncf.io/networks"
+	defaultNetworkAnnotationKey  = "v1.multus-cni.io/default-network"
+	networkSelectionNameRegexStr = `^[a-zA-Z0-9]([a-zA-Z0-9_.\-]*[a-zA-Z0-9])?$`
+)
+
+var (
+	networkSelectionNameRegex = regexp.MustCompile(networkSelectionNameRegexStr)
+
+	codecs        = serializer.NewCodecFactory(k8sruntime.NewScheme())
+	controlSwitch *controlswitches.ControlSwitches
+
+	// nadClient is used to look up NetworkAttachmentDefinitions referenced by
+	// a pod's network selections. It is nil until SetNadClient is called,
+	// which the webhook's main wires up against a real clientset at startup
+	// and unit tests wire up against a fake one.
+	nadClient nadclientset.Interface
+)
+
+// SetControlSwitches stores the resolved feature toggles in the package.
+// Neither MutateHandler nor ValidateHandler reads them.
+func SetControlSwitches(switches *controlswitches.ControlSwitches) {
+	controlSwitch = switches
+}
+
+// SetNadClient stores the clientset used to resolve NetworkAttachmentDefinitions
+// during validation.
+func SetNadClient(client nadclientset.Interface) {
+	nadClient = client
+}
+
+// deserializeAdmissionReview extracts an AdmissionReview from a raw HTTP
+// request body.
+func deserializeAdmissionReview(body []byte) (*admissionv1.AdmissionReview, error) {
+	ar := &admissionv1.AdmissionReview{}
+	_, _, err := codecs.UniversalDeserializer().Decode(body, nil, ar)
+
+	// As json is a subset of yaml, this should ensure we don't reject yaml
+	// reviews as well as json ones.
+	if err != nil {
+		return nil, err
+	}
+	if ar.Request == nil {
+		return nil, fmt.Errorf("request is empty")
+	}
+	return ar, nil
+}
+
+// deserializePod extracts the Pod object carried by an AdmissionReview's
+// request.
+func deserializePod(ar *admissionv1.AdmissionReview) (*corev1.Pod, error) {
+	pod := corev1.Pod{}
+	if err := json.Unmarshal(ar.Request.Object.Raw, &pod); err != nil {
+		return nil, err
+	}
+	return &pod, nil
+}
+
+// deserializeNetworkAttachmentDefinition extracts the NetworkAttachmentDefinition
+// object carried by an AdmissionReview's request.
+func deserializeNetworkAttachmentDefinition(ar *admissionv1.AdmissionReview) (*nadv1.NetworkAttachmentDefinition, error) {
+	nad := nadv1.NetworkAttachmentDefinition{}
+	if err := json.Unmarshal(ar.Request.Object.Raw, &nad); err != nil {
+		return nil, err
+	}
+	return &nad, nil
+}
+
+// prepareAdmissionReviewResponse fills in the Response half of an
+// AdmissionReview whose Request has already been populated.
+func prepareAdmissionReviewResponse(allowed bool, message string, ar *admissionv1.AdmissionReview) error {
+	if ar.Request == nil {
+		return fmt.Errorf("received empty AdmissionReview request")
+	}
+
+	ar.Response = &admissionv1.AdmissionResponse{
+		UID:     ar.Request.UID,
+		Allowed: allowed,
+	}
+	if message != "" {
+		ar.Response.Result = &metav1.Status{
+			Message: message,
+		}
+	}
+	return nil
+}
+
+// writeResponse marshals an AdmissionReview and writes it to w.
+func writeResponse(w http.ResponseWriter, ar *admissionv1.AdmissionReview) {
+	resp, err := json.Marshal(ar)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error marshalling response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(resp); err != nil {
+		http.Error(w, fmt.Sprintf("error writing response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// readAdmissionRequest validates the incoming HTTP request and decodes its
+// body into an AdmissionReview of whichever version the apiserver sent -
+// admission.k8s.io/v1 or v1beta1 - writing an error response and returning
+// a nil review if anything is wrong with the request itself.
+func readAdmissionRequest(w http.ResponseWriter, r *http.Request) admissionReview {
+	var body []byte
+	if r.Body != nil {
+		if data, err := ioutil.ReadAll(r.Body); err == nil {
+			body = data
+		}
+	}
+	if len(body) == 0 {
+		http.Error(w, "empty request body", http.StatusBadRequest)
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/json" {
+		http.Error(w, fmt.Sprintf("unsupported content type %q, expected application/json", contentType), http.StatusUnsupportedMediaType)
+		return nil
+	}
+
+	ar, err := decodeAdmissionReview(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error deserializing admission review: %v", err), http.StatusBadRequest)
+		return nil
+	}
+	return ar
+}
+
+// writeVersionedResponse marshals ar in its original wire version and
+// writes it to w.
+func writeVersionedResponse(w http.ResponseWriter, ar admissionReview) {
+	resp, err := ar.marshal()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error marshalling response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(resp); err != nil {
+		http.Error(w, fmt.Sprintf("error writing response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// MutateHandler handles AdmissionReview requests for pods about to be
+// created. It parses the pod's network selection annotations and always
+// admits the pod, reporting an annotation parse error as the response
+// message. It returns no JSON patch: resource requests/limits and
+// downward-API entries are not injected.
+func MutateHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	admissionRequestsInFlight.Inc()
+	defer admissionRequestsInFlight.Dec()
+
+	ar := readAdmissionRequest(w, r)
+	if ar == nil {
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult("", nil, "", "", 0, start, resultError)
+		return
+	}
+	v1Review := ar.v1()
+
+	pod, err := deserializePod(v1Review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error deserializing pod: %v", err), http.StatusBadRequest)
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult(string(v1Review.Request.UID), nil, "", "", 0, start, resultError)
+		return
+	}
+
+	var patch []nritypes.JsonPatchOperation
+	msg := ""
+	networks, _, err := parsePodNetworkAnnotations(*pod, patch)
+	if err != nil {
+		msg = err.Error()
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error marshalling patch: %v", err), http.StatusInternalServerError)
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(networks), "", 0, start, resultError)
+		return
+	}
+
+	if err := prepareAdmissionReviewResponse(true, msg, v1Review); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		observeAdmission(objectKindPod, resultError, start)
+		logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(networks), "", 0, start, resultError)
+		return
+	}
+	if len(patch) > 0 {
+		pt := admissionv1.PatchTypeJSONPatch
+		v1Review.Response.Patch = patchBytes
+		v1Review.Response.PatchType = &pt
+	}
+
+	ar.setResponse(v1Review.Response)
+	writeVersionedResponse(w, ar)
+	observeAdmission(objectKindPod, resultAllowed, start)
+	logAdmissionResult(string(v1Review.Request.UID), pod, networkNames(networks), "", len(patch), start, resultAllowed)
+}
+
+// networkNames renders a comma-separated list of "namespace/name" for the
+// given network selections, suitable for a single structured log field.
+func networkNames(elements []*types.NetworkSelectionElement) string {
+	names := make([]string, 0, len(elements))
+	for _, e := range elements {
+		names = append(names, e.Namespace+"/"+e.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// parsePodNetworkAnnotations resolves the network selections carried by a
+// pod's annotations, returning both the parsed selections found via the
+// networks key and the default-network key.
+func parsePodNetworkAnnotations(pod corev1.Pod, patch []nritypes.JsonPatchOperation) ([]*types.NetworkSelectionElement, []*types.NetworkSelectionElement, error) {
+	var networks, defaultNetworks []*types.NetworkSelectionElement
+
+	if raw, ok := getNetworkSelections(networksAnnotationKey, pod, patch); ok && raw != "" {
+		parsed, err := parsePodNetworkSelections(raw, pod.ObjectMeta.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		networks = parsed
+	}
+
+	if raw, ok := getNetworkSelections(defaultNetworkAnnotationKey, pod, patch); ok && raw != "" {
+		parsed, err := parsePodNetworkSelections(raw, pod.ObjectMeta.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		defaultNetworks = parsed
+	}
+
+	return networks, defaultNetworks, nil
+}
+
+// getNetworkSelections returns the value of annotateKey from the pod's own
+// annotations, which take precedence, falling back to a patch already staged
+// earlier in the mutation chain that adds that key under
+// /metadata/annotations.
+func getNetworkSelections(annotateKey string, pod corev1.Pod, patchs []nritypes.JsonPatchOperation) (string, bool) {
+	if pod.ObjectMeta.Annotations != nil {
+		if v, ok := pod.ObjectMeta.Annotations[annotateKey]; ok {
+			return v, true
+		}
+	}
+
+	for _, patch := range patchs {
+		if patch.Path != "/metadata/annotations" {
+			continue
+		}
+		values, ok := patch.Value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := values[annotateKey]; ok {
+			if s, ok := v.(string); ok {
+				return s, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// parsePodNetworkSelections parses the value of a k8s.v1.cni.cncf.io/networks
+// (or v1.multus-cni.io/default-network) annotation, which may be either a
+// comma-separated "ns/name@if" CSV list or a JSON array of
+// NetworkSelectionElement-shaped objects, into the list of networks a pod
+// selects. defaultNamespace is used for any entry that does not specify its
+// own namespace.
+func parsePodNetworkSelections(networks string, defaultNamespace string) ([]*types.NetworkSelectionElement, error) {
+	var list []*types.NetworkSelectionElement
+
+	if networks == "" {
+		return nil, nil
+	}
+
+	trimmed := strings.TrimSpace(networks)
+	if strings.HasPrefix(trimmed, "{") {
+		return nil, fmt.Errorf("invalid network selection elements: expected a JSON array, got a JSON object")
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &list); err != nil {
+			return nil, fmt.Errorf("failed to parse networks annotation as JSON: %v", err)
+		}
+		for _, item := range list {
+			if item.Namespace == "" {
+				item.Namespace = defaultNamespace
+			}
+			if err := validateNetworkSelectionElement(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	for _, entry := range strings.Split(trimmed, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		element, err := parseCsvNetworkSelection(entry, defaultNamespace)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, element)
+	}
+
+	return list, nil
+}
+
+// parseCsvNetworkSelection parses a single "[ns/]name[@if][#ip1;ip2][%mac]"
+// CSV entry. The "#" suffix carries a ";"-separated list of static IPs to
+// request (mirroring the JSON form's "ips" field) and the "%" suffix
+// carries a MAC address to request (mirroring "mac"); both are optional
+// and, when present, always appear after the interface suffix.
+func parseCsvNetworkSelection(entry string, defaultNamespace string) (*types.NetworkSelectionElement, error) {
+	namespace := defaultNamespace
+	name := entry
+	iface := ""
+	mac := ""
+	var ips []string
+
+	if idx := strings.LastIndex(name, "%"); idx >= 0 {
+		mac = name[idx+1:]
+		name = name[:idx]
+		if mac == "" {
+			return nil, fmt.Errorf("invalid network selection element %q: malformed mac suffix", entry)
+		}
+	}
+
+	if idx := strings.Index(name, "#"); idx >= 0 {
+		ipList := name[idx+1:]
+		name = name[:idx]
+		if ipList == "" {
+			return nil, fmt.Errorf("invalid network selection element %q: malformed ip suffix", entry)
+		}
+		ips = strings.Split(ipList, ";")
+	}
+
+	if idx := strings.Index(name, "@"); idx >= 0 {
+		iface = name[idx+1:]
+		name = name[:idx]
+		if strings.Contains(iface, "@") || iface == "" {
+			return nil, fmt.Errorf("invalid network selection element %q: malformed interface suffix", entry)
+		}
+	}
+
+	if idx := strings.Index(name, "/"); idx >= 0 {
+		namespace = name[:idx]
+		name = name[idx+1:]
+		if namespace == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid network selection element %q: malformed namespace/name", entry)
+		}
+	}
+
+	if !networkSelectionNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("invalid network selection element %q: invalid name %q", entry, name)
+	}
+
+	element := &types.NetworkSelectionElement{
+		Namespace:        namespace,
+		Name:             name,
+		InterfaceRequest: iface,
+		IPRequest:        ips,
+		MacRequest:       mac,
+	}
+	if err := validateNetworkSelectionElement(element); err != nil {
+		return nil, fmt.Errorf("invalid network selection element %q: %v", entry, err)
+	}
+	return element, nil
+}
+
+// validateNetworkSelectionElement checks the fields of a parsed selection
+// that carry user-controlled, format-sensitive data - whichever CSV or JSON
+// form they arrived through - so that a typo surfaces as a rejected
+// annotation instead of a pod silently missing its fixed IP or MAC.
+func validateNetworkSelectionElement(element *types.NetworkSelectionElement) error {
+	for _, ip := range element.IPRequest {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid ip address %q", ip)
+		}
+	}
+
+	if element.MacRequest != "" {
+		if _, err := net.ParseMAC(element.MacRequest); err != nil {
+			return fmt.Errorf("invalid mac address %q: %v", element.MacRequest, err)
+		}
+	}
+
+	if bw := element.BandwidthRequest; bw != nil {
+		if bw.IngressRate < 0 || bw.EgressRate < 0 {
+			return fmt.Errorf("bandwidth ingress/egress rate must not be negative")
+		}
+	}
+
+	return nil
+}
@@ -16,6 +16,7 @@ package webhook
 
 import (
 	"bytes"
+	"net"
 	"net/http"
 	"net/http/httptest"
 
@@ -333,5 +334,129 @@ var _ = Describe("Webhook", func() {
 			},
 			false,
 		),
+		Entry(
+			"csv - correct ip/mac extension",
+			"ns1/net1@eth0#10.0.0.1;10.0.0.2%aa:bb:cc:dd:ee:ff",
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace:        "ns1",
+					Name:             "net1",
+					InterfaceRequest: "eth0",
+					IPRequest:        []string{"10.0.0.1", "10.0.0.2"},
+					MacRequest:       "aa:bb:cc:dd:ee:ff",
+				},
+			},
+			false,
+		),
+		Entry(
+			"csv - correct ip extension without mac",
+			"net1#10.0.0.1",
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace: "default",
+					Name:      "net1",
+					IPRequest: []string{"10.0.0.1"},
+				},
+			},
+			false,
+		),
+		Entry(
+			"csv - malformed ip in extension",
+			"net1#not-an-ip",
+			emptyList,
+			true,
+		),
+		Entry(
+			"csv - malformed mac in extension",
+			"net1%not-a-mac",
+			emptyList,
+			true,
+		),
+		Entry(
+			"json - correct example with ips, mac, interface and bandwidth",
+			`[{"name": "net1", "namespace": "ns1", "interface": "net0", "ips": ["10.0.0.1"], "mac": "aa:bb:cc:dd:ee:ff", "bandwidth": {"ingressRate": 1000, "egressRate": 2000}}]`,
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace:        "ns1",
+					Name:             "net1",
+					InterfaceRequest: "net0",
+					IPRequest:        []string{"10.0.0.1"},
+					MacRequest:       "aa:bb:cc:dd:ee:ff",
+					BandwidthRequest: &types.BandwidthEntry{
+						IngressRate: 1000,
+						EgressRate:  2000,
+					},
+				},
+			},
+			false,
+		),
+		Entry(
+			"json - malformed ip",
+			`[{"name": "net1", "ips": ["not-an-ip"]}]`,
+			emptyList,
+			true,
+		),
+		Entry(
+			"json - malformed mac",
+			`[{"name": "net1", "mac": "not-a-mac"}]`,
+			emptyList,
+			true,
+		),
+		Entry(
+			"json - correct example with portMappings",
+			`[{"name": "net1", "portMappings": [{"containerPort": 80, "hostPort": 8080, "protocol": "tcp"}]}]`,
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace: "default",
+					Name:      "net1",
+					PortMappingsRequest: []*types.PortMapEntry{
+						{ContainerPort: 80, HostPort: 8080, Protocol: "tcp"},
+					},
+				},
+			},
+			false,
+		),
+		Entry(
+			"json - correct example with gateway",
+			`[{"name": "net1", "default-route": ["10.0.0.1"]}]`,
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace:      "default",
+					Name:           "net1",
+					GatewayRequest: []net.IP{net.ParseIP("10.0.0.1")},
+				},
+			},
+			false,
+		),
+		Entry(
+			"json - correct example with infiniband-guid",
+			`[{"name": "net1", "infiniband-guid": "00:11:22:33:44:55:66:77"}]`,
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace:             "default",
+					Name:                  "net1",
+					InfinibandGUIDRequest: "00:11:22:33:44:55:66:77",
+				},
+			},
+			false,
+		),
+		Entry(
+			"json - correct example with cni-args",
+			`[{"name": "net1", "cni-args": {"key": "value"}}]`,
+			[]*types.NetworkSelectionElement{
+				{
+					Namespace: "default",
+					Name:      "net1",
+					CNIArgs:   &map[string]interface{}{"key": "value"},
+				},
+			},
+			false,
+		),
+		Entry(
+			"json - malformed negative bandwidth",
+			`[{"name": "net1", "bandwidth": {"ingressRate": -1, "egressRate": 1000}}]`,
+			emptyList,
+			true,
+		),
 	)
 })